@@ -0,0 +1,154 @@
+package wasip1
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// fallbackDelay is how long DialContext waits for the primary address
+// family to succeed before it starts racing the fallback family, mirroring
+// the stdlib net package's Happy Eyeballs (RFC 6555) behavior.
+const fallbackDelay = 300 * time.Millisecond
+
+// addrList splits a set of resolved IPs into a primary and a fallback
+// address family so that DialContext can give the primary family a head
+// start before racing the fallback family alongside it.
+type addrList struct {
+	primary, fallback []net.IP
+}
+
+// partitionAddrs groups ips by address family, preferring IPv6 as the
+// primary family when the first address returned by the resolver is IPv6,
+// and IPv4 otherwise. This matches the order a dual-stack resolver response
+// is typically returned in and is the same heuristic the stdlib dialer uses.
+func partitionAddrs(ips []net.IP) addrList {
+	if len(ips) == 0 {
+		return addrList{}
+	}
+	preferIPv6 := ips[0].To4() == nil
+	var al addrList
+	for _, ip := range ips {
+		if (ip.To4() == nil) == preferIPv6 {
+			al.primary = append(al.primary, ip)
+		} else {
+			al.fallback = append(al.fallback, ip)
+		}
+	}
+	return al
+}
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+// DialContext dials address over network, and is installed by init as
+// http.DefaultTransport.DialContext so that outbound HTTP requests made by
+// a wasip1 guest work despite net.Resolver being unavailable on this
+// platform (see dialResolverNotSupported).
+//
+// When address resolves to more than one IP, DialContext races the dials
+// the way the stdlib's net package does for Happy Eyeballs: addresses of
+// the primary family (picked by partitionAddrs) are dialed immediately,
+// and the fallback family is started fallbackDelay later, giving the primary
+// family a head start before both are raced alongside each other. The first successful connection wins; the rest are
+// cancelled and closed.
+func DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return dialSingle(ctx, network, address)
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := lookupIP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 1 {
+		return dialSingle(ctx, network, net.JoinHostPort(ips[0].String(), port))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	al := partitionAddrs(ips)
+	results := make(chan dialResult)
+	var wg sync.WaitGroup
+
+	race := func(ips []net.IP, delay time.Duration) {
+		defer wg.Done()
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			defer timer.Stop()
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+		}
+		for _, ip := range ips {
+			wg.Add(1)
+			go func(ip net.IP) {
+				defer wg.Done()
+				conn, err := dialSingle(ctx, network, net.JoinHostPort(ip.String(), port))
+				select {
+				case results <- dialResult{conn, err}:
+				case <-ctx.Done():
+					if conn != nil {
+						conn.Close()
+					}
+				}
+			}(ip)
+		}
+	}
+
+	wg.Add(2)
+	go race(al.primary, 0)
+	go race(al.fallback, fallbackDelay)
+	go func() { wg.Wait(); close(results) }()
+
+	var errs []error
+	for res := range results {
+		if res.err == nil {
+			cancel()
+			go func() {
+				for r := range results {
+					if r.conn != nil {
+						r.conn.Close()
+					}
+				}
+			}()
+			return res.conn, nil
+		}
+		errs = append(errs, res.err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// lookupIP resolves host to a set of IPs, preferring the package's own
+// DefaultResolver (which works over TCP/DoT/DoH) when one has been
+// configured, and falling back to net.DefaultResolver otherwise.
+func lookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if DefaultResolver != nil {
+		return DefaultResolver.LookupIP(ctx, "ip", host)
+	}
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+// dialSingle dials a single, already-resolved address and wraps the
+// resulting connection with makeConn so that LocalAddr/RemoteAddr are
+// populated.
+func dialSingle(ctx context.Context, network, address string) (net.Conn, error) {
+	c, err := (&net.Dialer{}).DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return makeConn(c)
+}
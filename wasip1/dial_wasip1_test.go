@@ -0,0 +1,63 @@
+package wasip1
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestPartitionAddrs(t *testing.T) {
+	v4 := func(s string) net.IP { return net.ParseIP(s).To4() }
+	v6 := func(s string) net.IP { return net.ParseIP(s) }
+
+	tests := []struct {
+		name         string
+		ips          []net.IP
+		wantPrimary  []net.IP
+		wantFallback []net.IP
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:        "v4 only",
+			ips:         []net.IP{v4("192.0.2.1"), v4("192.0.2.2")},
+			wantPrimary: []net.IP{v4("192.0.2.1"), v4("192.0.2.2")},
+		},
+		{
+			name:        "v6 only",
+			ips:         []net.IP{v6("2001:db8::1")},
+			wantPrimary: []net.IP{v6("2001:db8::1")},
+		},
+		{
+			name:         "v4 first prefers v4 as primary",
+			ips:          []net.IP{v4("192.0.2.1"), v6("2001:db8::1")},
+			wantPrimary:  []net.IP{v4("192.0.2.1")},
+			wantFallback: []net.IP{v6("2001:db8::1")},
+		},
+		{
+			name:         "v6 first prefers v6 as primary",
+			ips:          []net.IP{v6("2001:db8::1"), v4("192.0.2.1")},
+			wantPrimary:  []net.IP{v6("2001:db8::1")},
+			wantFallback: []net.IP{v4("192.0.2.1")},
+		},
+		{
+			name:         "interleaved dual-stack response",
+			ips:          []net.IP{v4("192.0.2.1"), v6("2001:db8::1"), v4("192.0.2.2"), v6("2001:db8::2")},
+			wantPrimary:  []net.IP{v4("192.0.2.1"), v4("192.0.2.2")},
+			wantFallback: []net.IP{v6("2001:db8::1"), v6("2001:db8::2")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			al := partitionAddrs(tt.ips)
+			if !reflect.DeepEqual(al.primary, tt.wantPrimary) {
+				t.Errorf("primary = %v, want %v", al.primary, tt.wantPrimary)
+			}
+			if !reflect.DeepEqual(al.fallback, tt.wantFallback) {
+				t.Errorf("fallback = %v, want %v", al.fallback, tt.wantFallback)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package wasip1
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// hasRawSockets reports whether the host allows creating SOCK_RAW sockets.
+// Many WASI hosts deny raw sockets outright (no CAP_NET_RAW equivalent), so
+// this is probed once, by attempting to open and immediately close one, and
+// the result is cached.
+var hasRawSockets = sync.OnceValue(func() bool {
+	fd, err := socket(AF_INET, SOCK_RAW, 0)
+	if err != nil {
+		return false
+	}
+	syscall.Close(fd)
+	return true
+})
+
+// DialIP connects to the remote address using a raw IP socket for the
+// given protocol (e.g. "ip4:icmp", "ip6:ipv6-icmp"), returning a wrapped
+// *net.IPConn-shaped connection with LocalAddr/RemoteAddr populated.
+//
+// This mirrors the iprawsock_posix.go shape the stdlib's POSIX net
+// implementation exposes, which wasip1 otherwise lacks.
+func DialIP(network string, raddr *net.IPAddr) (net.Conn, error) {
+	if !hasRawSockets() {
+		return nil, &net.OpError{Op: "dial", Net: network, Addr: raddr, Err: syscall.EPERM}
+	}
+	sa, err := socketAddress(raddr)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := socket(family(raddr), SOCK_RAW, ipProtocol(network))
+	if err != nil {
+		return nil, newOpError("dial", raddr, err)
+	}
+	if err := connect(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, newOpError("dial", raddr, err)
+	}
+	bound, err := getsockname(fd)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, newOpError("dial", raddr, err)
+	}
+	return newRawIPConn(fd, sockaddrToIPAddr(bound), raddr)
+}
+
+// ListenIP listens for incoming IP packets addressed to laddr using a raw
+// IP socket for the given protocol, matching DialIP's protocol selection.
+// The returned connection is unconnected, so callers that need to learn a
+// packet's real sender (or reply to a specific one) should use its
+// ReadFrom/WriteTo methods rather than Read/Write.
+func ListenIP(network string, laddr *net.IPAddr) (net.Conn, error) {
+	if !hasRawSockets() {
+		return nil, &net.OpError{Op: "listen", Net: network, Addr: laddr, Err: syscall.EPERM}
+	}
+	sa, err := socketAddress(laddr)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := socket(family(laddr), SOCK_RAW, ipProtocol(network))
+	if err != nil {
+		return nil, newOpError("listen", laddr, err)
+	}
+	if err := bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, newOpError("listen", laddr, err)
+	}
+	return newRawIPConn(fd, laddr, nil)
+}
+
+// rawIPConn wraps a SOCK_RAW file descriptor as a net.Conn. Its
+// LocalAddr/RemoteAddr are set directly at construction time rather than
+// via makeConn's getsockname/getpeername fixup: makeConn only recognizes
+// *net.Unix/UDP/TCP/IPConn, and rawIPConn is none of those, so the addresses
+// it's given here (the laddr/raddr DialIP/ListenIP already resolved) are the
+// only ones it will ever report.
+type rawIPConn struct {
+	fd    int
+	laddr net.Addr
+	raddr net.Addr // nil when unconnected, i.e. constructed by ListenIP
+}
+
+func newRawIPConn(fd int, laddr, raddr net.Addr) (*rawIPConn, error) {
+	return &rawIPConn{fd: fd, laddr: laddr, raddr: raddr}, nil
+}
+
+func (c *rawIPConn) Read(p []byte) (int, error) {
+	n, _, err := recvfrom(c.fd, p)
+	return n, err
+}
+
+func (c *rawIPConn) Write(p []byte) (int, error) {
+	return sendto(c.fd, p, nil)
+}
+
+// ReadFrom and WriteTo are what make a ListenIP conn usable as a raw-socket
+// listener in the way raw sockets normally are (e.g. an ICMP echo
+// responder): Read/Write above discard the sender and can't target a
+// specific peer, which works for DialIP's connected socket but not for
+// ListenIP's unconnected one, where every inbound packet can come from (and
+// every reply needs to go to) a different address. This mirrors
+// nativePacketConn's ReadFrom/WriteTo in packetconn_wasip1.go.
+func (c *rawIPConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	n, sa, err := recvfrom(c.fd, p)
+	if err != nil {
+		return 0, nil, err
+	}
+	return n, sockaddrToIPAddr(sa), nil
+}
+
+func (c *rawIPConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	sa, err := socketAddress(addr)
+	if err != nil {
+		return 0, err
+	}
+	return sendto(c.fd, p, sa)
+}
+
+func (c *rawIPConn) Close() error         { return syscall.Close(c.fd) }
+func (c *rawIPConn) LocalAddr() net.Addr  { return c.laddr }
+func (c *rawIPConn) RemoteAddr() net.Addr { return c.raddr }
+
+func (c *rawIPConn) SetDeadline(t time.Time) error      { return setDeadline(c.fd, t) }
+func (c *rawIPConn) SetReadDeadline(t time.Time) error  { return setReadDeadline(c.fd, t) }
+func (c *rawIPConn) SetWriteDeadline(t time.Time) error { return setWriteDeadline(c.fd, t) }
+
+// ipProtocol maps a "ip"/"ip4"/"ip6" network string with an optional
+// ":protocol" suffix (as accepted by net.DialIP, e.g. "ip4:icmp") to the
+// corresponding IP protocol number. Only the "icmp" and "ipv6-icmp" names
+// are recognized; any other suffix (including a numeric one), or none,
+// yields 0.
+func ipProtocol(network string) int {
+	i := strings.IndexByte(network, ':')
+	if i < 0 {
+		return 0
+	}
+	switch network[i+1:] {
+	case "icmp":
+		return IPPROTO_ICMP
+	case "ipv6-icmp":
+		return IPPROTO_ICMPV6
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,70 @@
+package wasip1
+
+import "net"
+
+// listener wraps a net.Listener so that each accepted connection is passed
+// through makeConn, the same fixup the client side applies in DialContext,
+// giving Accept's connections working LocalAddr/RemoteAddr.
+type listener struct {
+	net.Listener
+}
+
+// Listen announces on the local address, returning a net.Listener whose
+// Accept method returns connections with LocalAddr/RemoteAddr populated.
+// network must be "tcp", "tcp4", or "tcp6".
+//
+// This fills the one major gap DialContext doesn't cover: a wasip1 guest
+// using this package can dial out, but without Listen it has no way to
+// accept inbound connections through the standard net.Listener interface.
+//
+// Unlike the client side, there is no way to make this transparent to
+// unmodified callers: http.Transport exposes a DialContext hook that init()
+// installs into http.DefaultTransport, but net/http.Server and net.Listen
+// have no equivalent seam to intercept — a caller that wants this package's
+// makeConn fixup on the server side has to call wasip1.Listen explicitly
+// (e.g. pass its result to http.Server.Serve) rather than http.ListenAndServe
+// or net.Listen picking it up on their own.
+func Listen(network, address string) (net.Listener, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, &net.OpError{Op: "listen", Net: network, Err: net.UnknownNetworkError(network)}
+	}
+	laddr, err := net.ResolveTCPAddr(network, address)
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.ListenTCP(network, laddr)
+	if err != nil {
+		return nil, err
+	}
+	return &listener{l}, nil
+}
+
+// ListenUnix is the Unix domain socket counterpart of Listen.
+func ListenUnix(network, address string) (net.Listener, error) {
+	if network != "unix" {
+		return nil, &net.OpError{Op: "listen", Net: network, Err: net.UnknownNetworkError(network)}
+	}
+	laddr, err := net.ResolveUnixAddr(network, address)
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.ListenUnix(network, laddr)
+	if err != nil {
+		return nil, err
+	}
+	return &listener{l}, nil
+}
+
+// Accept waits for and returns the next connection, wrapping it with
+// makeConn the same way Go's own fd.accept does internally via
+// setAddr(localSockname(...), toAddr(rsa)) for platforms where this
+// information is already populated.
+func (l *listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return makeConn(c)
+}
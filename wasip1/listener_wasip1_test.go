@@ -0,0 +1,75 @@
+package wasip1
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestListenRejectsUnknownNetwork(t *testing.T) {
+	_, err := Listen("udp", "127.0.0.1:0")
+	if err == nil {
+		t.Fatal("expected error for network \"udp\", got nil")
+	}
+	opErr, ok := err.(*net.OpError)
+	if !ok {
+		t.Fatalf("err = %T, want *net.OpError", err)
+	}
+	if opErr.Op != "listen" || opErr.Net != "udp" {
+		t.Errorf("opErr = %+v, want Op=listen Net=udp", opErr)
+	}
+}
+
+func TestListenUnixRejectsUnknownNetwork(t *testing.T) {
+	_, err := ListenUnix("unixgram", "/tmp/test.sock")
+	if err == nil {
+		t.Fatal("expected error for network \"unixgram\", got nil")
+	}
+	opErr, ok := err.(*net.OpError)
+	if !ok {
+		t.Fatalf("err = %T, want *net.OpError", err)
+	}
+	if opErr.Op != "listen" || opErr.Net != "unixgram" {
+		t.Errorf("opErr = %+v, want Op=listen Net=unixgram", opErr)
+	}
+}
+
+func TestListenTCP(t *testing.T) {
+	l, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close()
+
+	if _, ok := l.(*listener); !ok {
+		t.Fatalf("Listen returned %T, want *listener", l)
+	}
+	if l.Addr() == nil {
+		t.Fatal("Addr() returned nil")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer c.Close()
+		if c.LocalAddr() == nil || c.RemoteAddr() == nil {
+			done <- errors.New("accepted conn has nil LocalAddr/RemoteAddr")
+			return
+		}
+		done <- nil
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	conn.Close()
+
+	if err := <-done; err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+}
@@ -22,11 +22,15 @@ func dialResolverNotSupported(ctx context.Context, network, address string) (net
 }
 
 func init() {
-	net.DefaultResolver.Dial = dialResolverNotSupported
+	net.DefaultResolver.Dial = dialResolver
 
 	if t, ok := http.DefaultTransport.(*http.Transport); ok {
 		t.DialContext = DialContext
 	}
+
+	if r, err := NewResolver(ResolverConfig{Mode: TCP}); err == nil {
+		DefaultResolver = r
+	}
 }
 
 func newOpError(op string, addr net.Addr, err error) error {
@@ -69,6 +73,8 @@ func socketType(addr net.Addr) (int, error) {
 		return SOCK_STREAM, nil
 	case "udp", "unixgram":
 		return SOCK_DGRAM, nil
+	case "ip", "ip4", "ip6":
+		return SOCK_RAW, nil
 	default:
 		return -1, syscall.EPROTOTYPE
 	}
@@ -142,6 +148,9 @@ func makeConn(c net.Conn) (net.Conn, error) {
 		case *net.TCPConn:
 			laddr = sockaddrToTCPAddr(addr)
 			raddr = sockaddrToTCPAddr(peer)
+		case *net.IPConn:
+			laddr = sockaddrToIPAddr(addr)
+			raddr = sockaddrToIPAddr(peer)
 		}
 	})
 	if err == nil {
@@ -182,6 +191,11 @@ func sockaddrToUDPAddr(addr sockaddr) net.Addr {
 	}
 }
 
+func sockaddrToIPAddr(addr sockaddr) net.Addr {
+	ip, _ := sockaddrIPAndPort(addr)
+	return &net.IPAddr{IP: ip}
+}
+
 func sockaddrIPAndPort(addr sockaddr) (net.IP, int) {
 	switch a := addr.(type) {
 	case *sockaddrInet4:
@@ -0,0 +1,396 @@
+package wasip1
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// UDPRelay, when non-nil, is used to emulate UDP-style datagrams on hosts
+// whose WASI implementation does not support native datagram sockets
+// (SOCK_DGRAM). It is dialed once per PacketConn and datagrams are framed
+// over the resulting stream as a tagged source/destination address (see
+// encodeRelayAddr) followed by a 2-byte big-endian length-prefixed
+// payload, so a relay fanning out to multiple peers on behalf of a single
+// ListenPacket conn can be told apart on ReadFrom and targeted on WriteTo.
+//
+// Hosts implementing the wasi-sockets (preview 2) datagram API do not need
+// this hook; it exists purely as a fallback for plain WASI preview 1 hosts.
+var UDPRelay func(network, address string) (net.Conn, error)
+
+// ListenPacket announces on the local address, returning a net.PacketConn.
+// network must be "udp", "udp4", "udp6", or "unixgram". When the host
+// supports native datagram sockets, ListenPacket uses them directly;
+// otherwise it falls back to the relay emulation configured via UDPRelay,
+// returning an error if no relay has been configured.
+func ListenPacket(network, address string) (net.PacketConn, error) {
+	switch network {
+	case "udp", "udp4", "udp6", "unixgram":
+	default:
+		return nil, &net.OpError{Op: "listen", Net: network, Err: syscall.EPROTOTYPE}
+	}
+
+	if hasNativeDgramSockets() {
+		return listenNativePacket(network, address)
+	}
+	return newRelayPacketConn(network, address)
+}
+
+// DialUDP connects to the remote address over a UDP-style datagram socket,
+// following the same native-or-relay selection as ListenPacket.
+func DialUDP(network, address string) (net.Conn, error) {
+	switch network {
+	case "udp", "udp4", "udp6":
+	default:
+		return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.EPROTOTYPE}
+	}
+	if hasNativeDgramSockets() {
+		return dialNativeDgram(network, address)
+	}
+	return dialRelay(network, address)
+}
+
+// DialUnixgram is the unixgram counterpart of DialUDP.
+func DialUnixgram(network, address string) (net.Conn, error) {
+	if network != "unixgram" {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.EPROTOTYPE}
+	}
+	if hasNativeDgramSockets() {
+		return dialNativeDgram(network, address)
+	}
+	return dialRelay(network, address)
+}
+
+// hasNativeDgramSockets reports whether the host implements SOCK_DGRAM
+// sockets, which plain WASI preview 1 hosts do not but wasi-sockets
+// (preview 2) hosts do. The result is cached after the first probe.
+var hasNativeDgramSockets = sync.OnceValue(func() bool {
+	fd, err := socket(AF_INET, SOCK_DGRAM, 0)
+	if err != nil {
+		return false
+	}
+	syscall.Close(fd)
+	return true
+})
+
+// resolveDgramAddr resolves address for the given datagram network, which is
+// either "udp"/"udp4"/"udp6" (net.ResolveUDPAddr) or "unixgram"
+// (net.ResolveUnixAddr) — net.ResolveUDPAddr rejects "unixgram" outright, so
+// ListenPacket/DialUnixgram need to branch here rather than always resolving
+// as UDP.
+func resolveDgramAddr(network, address string) (net.Addr, error) {
+	if network == "unixgram" {
+		return net.ResolveUnixAddr(network, address)
+	}
+	return net.ResolveUDPAddr(network, address)
+}
+
+// listenNativePacket binds a SOCK_DGRAM socket to address and wraps it as a
+// net.PacketConn, for hosts that implement native WASI datagram sockets.
+func listenNativePacket(network, address string) (net.PacketConn, error) {
+	laddr, err := resolveDgramAddr(network, address)
+	if err != nil {
+		return nil, err
+	}
+	sa, err := socketAddress(laddr)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := socket(family(laddr), SOCK_DGRAM, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	return newNativePacketConn(fd, network)
+}
+
+// dialNativeDgram connects a SOCK_DGRAM socket to address.
+func dialNativeDgram(network, address string) (net.Conn, error) {
+	raddr, err := resolveDgramAddr(network, address)
+	if err != nil {
+		return nil, err
+	}
+	sa, err := socketAddress(raddr)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := socket(family(raddr), SOCK_DGRAM, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := connect(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	pc, err := newNativePacketConn(fd, network)
+	if err != nil {
+		return nil, err
+	}
+	pc.raddr = raddr
+	return pc, nil
+}
+
+// nativePacketConn wraps a SOCK_DGRAM file descriptor, implementing both
+// net.PacketConn (for ListenPacket) and net.Conn (for the connected case
+// used by DialUDP/DialUnixgram).
+type nativePacketConn struct {
+	fd      int
+	network string
+	laddr   net.Addr
+	raddr   net.Addr // set only when connected via dialNativeDgram
+}
+
+func newNativePacketConn(fd int, network string) (*nativePacketConn, error) {
+	sa, err := getsockname(fd)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	return &nativePacketConn{fd: fd, network: network, laddr: dgramAddr(network, sa)}, nil
+}
+
+// dgramAddr converts sa the same way resolveDgramAddr picks its addr type:
+// *net.UnixAddr for "unixgram", *net.UDPAddr otherwise. sockaddrToUDPAddr
+// doesn't understand a *sockaddrUnix (its sockaddrIPAndPort silently
+// returns a nil IP for it), so this has to branch on network rather than
+// always going through sockaddrToUDPAddr.
+func dgramAddr(network string, sa sockaddr) net.Addr {
+	if network == "unixgram" {
+		return sockaddrToUnixAddr(sa)
+	}
+	return sockaddrToUDPAddr(sa)
+}
+
+func (c *nativePacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	n, sa, err := recvfrom(c.fd, p)
+	if err != nil {
+		return 0, nil, err
+	}
+	return n, dgramAddr(c.network, sa), nil
+}
+
+func (c *nativePacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	sa, err := socketAddress(addr)
+	if err != nil {
+		return 0, err
+	}
+	return sendto(c.fd, p, sa)
+}
+
+func (c *nativePacketConn) Read(p []byte) (int, error) {
+	n, _, err := c.ReadFrom(p)
+	return n, err
+}
+
+func (c *nativePacketConn) Write(p []byte) (int, error) {
+	n, err := sendto(c.fd, p, nil)
+	return n, err
+}
+
+func (c *nativePacketConn) Close() error                       { return syscall.Close(c.fd) }
+func (c *nativePacketConn) LocalAddr() net.Addr                { return c.laddr }
+func (c *nativePacketConn) RemoteAddr() net.Addr               { return c.raddr }
+func (c *nativePacketConn) SetDeadline(t time.Time) error      { return setDeadline(c.fd, t) }
+func (c *nativePacketConn) SetReadDeadline(t time.Time) error  { return setReadDeadline(c.fd, t) }
+func (c *nativePacketConn) SetWriteDeadline(t time.Time) error { return setWriteDeadline(c.fd, t) }
+
+// relayPacketConn emulates net.PacketConn's ReadFrom/WriteTo semantics over
+// a single length-prefixed TCP connection to a relay, for hosts that do not
+// implement native WASI datagram sockets.
+type relayPacketConn struct {
+	net.Conn
+	network string
+	laddr   net.Addr
+
+	mu     sync.Mutex
+	peer   net.Addr // destination used by Write; set by dialRelay and by every WriteTo
+	closed bool
+}
+
+func newRelayPacketConn(network, address string) (*relayPacketConn, error) {
+	if UDPRelay == nil {
+		return nil, errors.New("wasip1: no native datagram sockets and no UDPRelay configured")
+	}
+	c, err := UDPRelay(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &relayPacketConn{Conn: c, network: network, laddr: &netAddr{network, address}}, nil
+}
+
+func dialRelay(network, address string) (net.Conn, error) {
+	peer, err := resolveDgramAddr(network, address)
+	if err != nil {
+		return nil, err
+	}
+	pc, err := newRelayPacketConn(network, address)
+	if err != nil {
+		return nil, err
+	}
+	// pc.peer must be a type socketAddress (and so encodeRelayAddr) knows
+	// how to turn into a sockaddr; the raw, unparsed netAddr it's dialed
+	// with here isn't one of those.
+	pc.peer = peer
+	return pc, nil
+}
+
+// ReadFrom reads the next relay frame, which carries the real sender
+// address (see encodeRelayAddr) ahead of the length-prefixed payload, so
+// that a ListenPacket conn fed by a relay serving multiple peers can tell
+// them apart instead of reporting every datagram as coming from the single
+// TCP connection to the relay.
+func (c *relayPacketConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	addr, err = decodeRelayAddr(c.Conn)
+	if err != nil {
+		return 0, nil, err
+	}
+	var hdr [2]byte
+	if _, err := io.ReadFull(c.Conn, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	size := int(binary.BigEndian.Uint16(hdr[:]))
+	if size > len(p) {
+		return 0, nil, errors.New("wasip1: datagram larger than buffer")
+	}
+	if _, err := io.ReadFull(c.Conn, p[:size]); err != nil {
+		return 0, nil, err
+	}
+	return size, addr, nil
+}
+
+// WriteTo sends p to addr as a single relay frame: the destination address
+// (see encodeRelayAddr), a 2-byte length, then the payload. The whole frame
+// is assembled and written in one Conn.Write call so that concurrent
+// WriteTo calls from multiple goroutines can't interleave their frames.
+func (c *relayPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if len(p) > 0xffff {
+		return 0, errors.New("wasip1: datagram too large to relay")
+	}
+	c.mu.Lock()
+	c.peer = addr
+	c.mu.Unlock()
+
+	addrBytes, err := encodeRelayAddr(addr)
+	if err != nil {
+		return 0, err
+	}
+	frame := make([]byte, 0, len(addrBytes)+2+len(p))
+	frame = append(frame, addrBytes...)
+	frame = binary.BigEndian.AppendUint16(frame, uint16(len(p)))
+	frame = append(frame, p...)
+	if _, err := c.Conn.Write(frame); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *relayPacketConn) Read(p []byte) (int, error) {
+	n, _, err := c.ReadFrom(p)
+	return n, err
+}
+
+func (c *relayPacketConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	peer := c.peer
+	c.mu.Unlock()
+	return c.WriteTo(p, peer)
+}
+
+// relayAddr tags identify which sockaddr shape follows in a relay frame.
+const (
+	relayAddrInet4 = 4
+	relayAddrInet6 = 6
+	relayAddrUnix  = 1
+)
+
+// encodeRelayAddr renders addr as a tagged sockaddr, reusing socketAddress
+// so the wire shape matches the sockaddrInet4/sockaddrInet6/sockaddrUnix
+// types the native datagram path already works with.
+func encodeRelayAddr(addr net.Addr) ([]byte, error) {
+	sa, err := socketAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	switch a := sa.(type) {
+	case *sockaddrInet4:
+		b := make([]byte, 1+4+2)
+		b[0] = relayAddrInet4
+		copy(b[1:5], a.addr[:])
+		binary.BigEndian.PutUint16(b[5:7], uint16(a.port))
+		return b, nil
+	case *sockaddrInet6:
+		b := make([]byte, 1+16+2)
+		b[0] = relayAddrInet6
+		copy(b[1:17], a.addr[:])
+		binary.BigEndian.PutUint16(b[17:19], uint16(a.port))
+		return b, nil
+	case *sockaddrUnix:
+		name := []byte(a.name)
+		b := make([]byte, 1+2+len(name))
+		b[0] = relayAddrUnix
+		binary.BigEndian.PutUint16(b[1:3], uint16(len(name)))
+		copy(b[3:], name)
+		return b, nil
+	default:
+		return nil, errors.New("wasip1: unsupported relay address type")
+	}
+}
+
+// decodeRelayAddr reads a tagged sockaddr written by encodeRelayAddr off r
+// and converts it to a net.Addr via sockaddrToUDPAddr/sockaddrToUnixAddr,
+// the same helpers makeConn uses for native connections.
+func decodeRelayAddr(r io.Reader) (net.Addr, error) {
+	var tag [1]byte
+	if _, err := io.ReadFull(r, tag[:]); err != nil {
+		return nil, err
+	}
+	switch tag[0] {
+	case relayAddrInet4:
+		var b [6]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return nil, err
+		}
+		sa := &sockaddrInet4{addr: ([4]byte)(b[:4]), port: int(binary.BigEndian.Uint16(b[4:6]))}
+		return sockaddrToUDPAddr(sa), nil
+	case relayAddrInet6:
+		var b [18]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return nil, err
+		}
+		sa := &sockaddrInet6{addr: ([16]byte)(b[:16]), port: int(binary.BigEndian.Uint16(b[16:18]))}
+		return sockaddrToUDPAddr(sa), nil
+	case relayAddrUnix:
+		var lenBuf [2]byte
+		if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+			return nil, err
+		}
+		name := make([]byte, binary.BigEndian.Uint16(lenBuf[:]))
+		if _, err := io.ReadFull(r, name); err != nil {
+			return nil, err
+		}
+		return sockaddrToUnixAddr(&sockaddrUnix{name: string(name)}), nil
+	default:
+		return nil, fmt.Errorf("wasip1: corrupt relay address tag %d", tag[0])
+	}
+}
+
+func (c *relayPacketConn) LocalAddr() net.Addr { return c.laddr }
+
+func (c *relayPacketConn) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+	return c.Conn.Close()
+}
@@ -0,0 +1,475 @@
+package wasip1
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Mode selects the transport a Resolver uses to reach its configured name
+// servers. WASI preview 1 has no UDP, so every mode here is carried over a
+// stream: a plain DNS-over-TCP connection (RFC 7766), DNS-over-TLS (RFC
+// 7858), or DNS-over-HTTPS (RFC 8484).
+type Mode int
+
+const (
+	TCP Mode = iota
+	DoT
+	DoH
+)
+
+// ResolverConfig configures a Resolver.
+type ResolverConfig struct {
+	// Servers are dialed in order until one answers. For Mode TCP and DoT
+	// these are "host:port" pairs (port defaults to 53 for TCP and 853 for
+	// DoT when omitted); for Mode DoH these are complete
+	// "https://host/dns-query" style URLs.
+	Servers []string
+	Mode    Mode
+}
+
+// Resolver implements hostname resolution entirely over TCP, for use on
+// GOOS=wasip1 where net.Resolver's usual UDP-based pure-Go resolver cannot
+// run (see dialResolverNotSupported).
+type Resolver struct {
+	config ResolverConfig
+	client *http.Client // only used in DoH mode
+}
+
+// NewResolver builds a Resolver from cfg. If cfg.Servers is empty, it
+// attempts to parse /etc/resolv.conf (available to a wasip1 guest through a
+// WASI preopen mapping that directory); if that file isn't present, the
+// caller must supply cfg.Servers explicitly.
+func NewResolver(cfg ResolverConfig) (*Resolver, error) {
+	if len(cfg.Servers) == 0 {
+		servers, err := parseResolvConf("/etc/resolv.conf")
+		if err != nil {
+			return nil, fmt.Errorf("wasip1: no resolver servers configured and /etc/resolv.conf unavailable: %w", err)
+		}
+		cfg.Servers = servers
+	}
+	if cfg.Mode == DoH {
+		// exchangeDoH's client dials through DialContext, which resolves
+		// hostnames via DefaultResolver once this Resolver is installed as
+		// it — so a hostname DoH server (e.g. "https://dns.google/dns-query")
+		// would recurse into itself resolving its own address. Require an
+		// IP-literal server to break that cycle; there's no bootstrap
+		// resolver to fall back to.
+		for _, server := range cfg.Servers {
+			u, err := url.Parse(server)
+			if err != nil {
+				return nil, fmt.Errorf("wasip1: invalid DoH server %q: %w", server, err)
+			}
+			if net.ParseIP(u.Hostname()) == nil {
+				return nil, fmt.Errorf("wasip1: DoH server %q must be an IP literal, not a hostname", server)
+			}
+		}
+	}
+	r := &Resolver{config: cfg}
+	if cfg.Mode == DoH {
+		r.client = &http.Client{Transport: &http.Transport{DialContext: DialContext}}
+	}
+	return r, nil
+}
+
+// parseResolvConf extracts "nameserver" entries from a resolv.conf(5) file.
+func parseResolvConf(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(servers) == 0 {
+		return nil, errors.New("no nameserver entries found")
+	}
+	return servers, nil
+}
+
+// LookupHost looks up the given host and returns a slice of its addresses,
+// in the textual form accepted by net.ParseIP.
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{host}, nil
+	}
+	var addrs []string
+	for _, qtype := range [...]uint16{dnsTypeA, dnsTypeAAAA} {
+		answers, err := r.query(ctx, host, qtype)
+		if err != nil {
+			continue
+		}
+		addrs = append(addrs, answers...)
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host}
+	}
+	return addrs, nil
+}
+
+// LookupIP looks up host using the resolver and returns a slice of that
+// host's IP addresses. The network argument ("ip", "ip4", or "ip6")
+// filters the address families returned, matching net.Resolver.LookupIP.
+func (r *Resolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	addrs, err := r.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			continue
+		}
+		isV4 := ip.To4() != nil
+		switch network {
+		case "ip4":
+			if !isV4 {
+				continue
+			}
+		case "ip6":
+			if isV4 {
+				continue
+			}
+		}
+		ips = append(ips, ip)
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no suitable address", Name: host}
+	}
+	return ips, nil
+}
+
+// LookupSRV does a SRV lookup for the given service, protocol, and domain
+// name, returning the records as *net.SRV values in the order the server
+// sent them; they are not sorted by priority or weight.
+func (r *Resolver) LookupSRV(ctx context.Context, service, proto, name string) ([]*net.SRV, error) {
+	target := fmt.Sprintf("_%s._%s.%s", service, proto, name)
+	answers, err := r.queryRaw(ctx, target, dnsTypeSRV)
+	if err != nil {
+		return nil, err
+	}
+	return parseSRVRecords(answers)
+}
+
+// LookupTXT returns the DNS TXT records for the given domain name.
+func (r *Resolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	answers, err := r.queryRaw(ctx, name, dnsTypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	return parseTXTRecords(answers)
+}
+
+// query resolves name for an A/AAAA qtype and returns the result addresses
+// as text.
+func (r *Resolver) query(ctx context.Context, name string, qtype uint16) ([]string, error) {
+	msg, err := r.queryRaw(ctx, name, qtype)
+	if err != nil {
+		return nil, err
+	}
+	return parseAddrRecords(msg, qtype)
+}
+
+// queryRaw sends a DNS query for name/qtype to the configured servers,
+// trying each in turn, and returns the raw answer records.
+func (r *Resolver) queryRaw(ctx context.Context, name string, qtype uint16) ([]dnsRR, error) {
+	query, id := buildQuery(name, qtype)
+
+	var lastErr error
+	for _, server := range r.config.Servers {
+		raw, err := r.exchange(ctx, server, query)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		answers, err := parseResponse(raw, id)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return answers, nil
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no resolver servers configured")
+	}
+	return nil, lastErr
+}
+
+// exchange sends a single DNS message to server and returns the response,
+// using the transport selected by r.config.Mode.
+func (r *Resolver) exchange(ctx context.Context, server string, query []byte) ([]byte, error) {
+	switch r.config.Mode {
+	case DoH:
+		return r.exchangeDoH(ctx, server, query)
+	case DoT:
+		return r.exchangeStream(ctx, server, "853", query, true)
+	default:
+		return r.exchangeStream(ctx, server, "53", query, false)
+	}
+}
+
+func (r *Resolver) exchangeStream(ctx context.Context, server, defaultPort string, query []byte, useTLS bool) ([]byte, error) {
+	addr := server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		addr = net.JoinHostPort(server, defaultPort)
+	}
+	conn, err := DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if useTLS {
+		host, _, _ := net.SplitHostPort(addr)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	var lenPrefix [2]byte
+	binary.BigEndian.PutUint16(lenPrefix[:], uint16(len(query)))
+	if _, err := conn.Write(lenPrefix[:]); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(conn, lenPrefix[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(lenPrefix[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Resolver) exchangeDoH(ctx context.Context, server string, query []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("content-type", "application/dns-message")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("wasip1: DoH server %s returned status %d", server, resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// dialResolver is installed as net.DefaultResolver.Dial. The stdlib resolver
+// calls this hook with address already set to the validated "IP:port" of one
+// of its configured DNS servers (see net.(*Resolver).dial) — it isn't an
+// application hostname to resolve, just a server to connect to.
+//
+// The stdlib resolver tries network "udp" first and, per
+// net/dnsclient_unix.go's exchange, only falls back to "tcp" when a UDP
+// reply comes back truncated — not when the dial itself errors. Since this
+// platform has no generic UDP dial path (see packetconn_wasip1.go for why),
+// passing "udp" straight through to DialContext would make every dial fail
+// and the stdlib resolver would give up without ever retrying over TCP. TCP
+// is the only transport this hook can actually use, so network is forced to
+// "tcp" regardless of what the stdlib asks for.
+func dialResolver(ctx context.Context, network, address string) (net.Conn, error) {
+	if DefaultResolver == nil {
+		return dialResolverNotSupported(ctx, network, address)
+	}
+	return DialContext(ctx, "tcp", address)
+}
+
+// DefaultResolver is used by DialContext and by dialResolver, when non-nil,
+// to resolve hostnames over TCP/DoT/DoH instead of the disabled stdlib
+// resolver. This package's init sets it via NewResolver in TCP mode when
+// /etc/resolv.conf lists a nameserver; otherwise it is nil until assigned
+// explicitly.
+var DefaultResolver *Resolver
+
+const (
+	dnsTypeA    uint16 = 1
+	dnsTypeAAAA uint16 = 28
+	dnsTypeSRV  uint16 = 33
+	dnsTypeTXT  uint16 = 16
+	dnsClassIN  uint16 = 1
+)
+
+type dnsRR struct {
+	typ  uint16
+	data []byte
+}
+
+// buildQuery encodes a minimal single-question DNS query message for name
+// and qtype, returning the wire bytes and the transaction id used so the
+// response can be matched.
+func buildQuery(name string, qtype uint16) ([]byte, uint16) {
+	id := uint16(rand.Intn(1 << 16))
+	var buf bytes.Buffer
+	var header [12]byte
+	binary.BigEndian.PutUint16(header[0:], id)
+	header[2] = 0x01                          // RD (recursion desired)
+	binary.BigEndian.PutUint16(header[4:], 1) // QDCOUNT
+	buf.Write(header[:])
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		buf.WriteByte(byte(len(label)))
+		buf.WriteString(label)
+	}
+	buf.WriteByte(0)
+	var qtypeClass [4]byte
+	binary.BigEndian.PutUint16(qtypeClass[0:], qtype)
+	binary.BigEndian.PutUint16(qtypeClass[2:], dnsClassIN)
+	buf.Write(qtypeClass[:])
+	return buf.Bytes(), id
+}
+
+// parseResponse validates the DNS header against id and returns the answer
+// resource records.
+func parseResponse(msg []byte, id uint16) ([]dnsRR, error) {
+	if len(msg) < 12 {
+		return nil, errors.New("wasip1: truncated DNS response")
+	}
+	if binary.BigEndian.Uint16(msg[0:2]) != id {
+		return nil, errors.New("wasip1: DNS response id mismatch")
+	}
+	rcode := msg[3] & 0x0f
+	if rcode != 0 {
+		return nil, fmt.Errorf("wasip1: DNS response error, rcode=%d", rcode)
+	}
+	qdcount := binary.BigEndian.Uint16(msg[4:6])
+	ancount := binary.BigEndian.Uint16(msg[6:8])
+
+	off := 12
+	for i := 0; i < int(qdcount); i++ {
+		var err error
+		off, err = skipName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		off += 4 // qtype + qclass
+	}
+
+	rrs := make([]dnsRR, 0, ancount)
+	for i := 0; i < int(ancount); i++ {
+		var err error
+		off, err = skipName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		if off+10 > len(msg) {
+			return nil, errors.New("wasip1: truncated DNS answer")
+		}
+		typ := binary.BigEndian.Uint16(msg[off : off+2])
+		rdlen := int(binary.BigEndian.Uint16(msg[off+8 : off+10]))
+		off += 10
+		if off+rdlen > len(msg) {
+			return nil, errors.New("wasip1: truncated DNS rdata")
+		}
+		rrs = append(rrs, dnsRR{typ: typ, data: msg[off : off+rdlen]})
+		off += rdlen
+	}
+	return rrs, nil
+}
+
+// skipName advances past a (possibly compressed) DNS name starting at off
+// and returns the offset immediately after it.
+func skipName(msg []byte, off int) (int, error) {
+	for {
+		if off >= len(msg) {
+			return 0, errors.New("wasip1: truncated DNS name")
+		}
+		b := msg[off]
+		switch {
+		case b == 0:
+			return off + 1, nil
+		case b&0xc0 == 0xc0: // compression pointer
+			return off + 2, nil
+		default:
+			off += 1 + int(b)
+		}
+	}
+}
+
+func parseAddrRecords(rrs []dnsRR, qtype uint16) ([]string, error) {
+	var addrs []string
+	for _, rr := range rrs {
+		if rr.typ != qtype {
+			continue
+		}
+		addrs = append(addrs, net.IP(rr.data).String())
+	}
+	return addrs, nil
+}
+
+func parseSRVRecords(rrs []dnsRR) ([]*net.SRV, error) {
+	var srvs []*net.SRV
+	for _, rr := range rrs {
+		if rr.typ != dnsTypeSRV || len(rr.data) < 6 {
+			continue
+		}
+		srvs = append(srvs, &net.SRV{
+			Priority: binary.BigEndian.Uint16(rr.data[0:2]),
+			Weight:   binary.BigEndian.Uint16(rr.data[2:4]),
+			Port:     binary.BigEndian.Uint16(rr.data[4:6]),
+			Target:   decodeName(rr.data[6:]),
+		})
+	}
+	return srvs, nil
+}
+
+func parseTXTRecords(rrs []dnsRR) ([]string, error) {
+	var texts []string
+	for _, rr := range rrs {
+		data := rr.data
+		for len(data) > 0 {
+			n := int(data[0])
+			if n+1 > len(data) {
+				break
+			}
+			texts = append(texts, string(data[1:1+n]))
+			data = data[1+n:]
+		}
+	}
+	return texts, nil
+}
+
+// decodeName decodes an uncompressed DNS name, used for the rdata of
+// records (such as SRV targets) that embed a name after the outer message
+// has already been parsed.
+func decodeName(data []byte) string {
+	var labels []string
+	for len(data) > 0 && data[0] != 0 {
+		n := int(data[0])
+		if n+1 > len(data) {
+			break
+		}
+		labels = append(labels, string(data[1:1+n]))
+		data = data[1+n:]
+	}
+	return strings.Join(labels, ".")
+}
@@ -0,0 +1,164 @@
+package wasip1
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+func TestBuildQueryParseResponseRoundTrip(t *testing.T) {
+	query, id := buildQuery("example.com", dnsTypeA)
+
+	if got := binary.BigEndian.Uint16(query[0:2]); got != id {
+		t.Fatalf("query id = %d, want %d", got, id)
+	}
+	if query[2]&0x01 == 0 {
+		t.Fatalf("RD bit not set in query header")
+	}
+	if got := binary.BigEndian.Uint16(query[4:6]); got != 1 {
+		t.Fatalf("QDCOUNT = %d, want 1", got)
+	}
+
+	resp := buildTestResponse(t, id, query, []dnsRR{
+		{typ: dnsTypeA, data: net.ParseIP("192.0.2.1").To4()},
+	})
+	answers, err := parseResponse(resp, id)
+	if err != nil {
+		t.Fatalf("parseResponse: %v", err)
+	}
+	addrs, err := parseAddrRecords(answers, dnsTypeA)
+	if err != nil {
+		t.Fatalf("parseAddrRecords: %v", err)
+	}
+	want := []string{"192.0.2.1"}
+	if len(addrs) != 1 || addrs[0] != want[0] {
+		t.Errorf("addrs = %v, want %v", addrs, want)
+	}
+}
+
+func TestParseResponseRejectsMismatchedID(t *testing.T) {
+	_, id := buildQuery("example.com", dnsTypeA)
+	resp := buildTestResponse(t, id, nil, nil)
+	// Tamper with the id in the response.
+	binary.BigEndian.PutUint16(resp[0:2], id+1)
+
+	if _, err := parseResponse(resp, id); err == nil {
+		t.Fatal("expected error for mismatched DNS response id, got nil")
+	}
+}
+
+func TestParseResponseRejectsErrorRcode(t *testing.T) {
+	_, id := buildQuery("example.com", dnsTypeA)
+	resp := buildTestResponse(t, id, nil, nil)
+	resp[3] |= 0x03 // NXDOMAIN
+
+	if _, err := parseResponse(resp, id); err == nil {
+		t.Fatal("expected error for non-zero rcode, got nil")
+	}
+}
+
+func TestSkipNameCompressionPointer(t *testing.T) {
+	msg := []byte{0x03, 'f', 'o', 'o', 0x00, 0xc0, 0x00}
+	off, err := skipName(msg, 0)
+	if err != nil {
+		t.Fatalf("skipName: %v", err)
+	}
+	if off != 5 {
+		t.Fatalf("offset after uncompressed name = %d, want 5", off)
+	}
+	off, err = skipName(msg, 5)
+	if err != nil {
+		t.Fatalf("skipName (pointer): %v", err)
+	}
+	if off != 7 {
+		t.Fatalf("offset after compression pointer = %d, want 7", off)
+	}
+}
+
+func TestParseSRVRecords(t *testing.T) {
+	data := make([]byte, 6)
+	binary.BigEndian.PutUint16(data[0:2], 10)   // priority
+	binary.BigEndian.PutUint16(data[2:4], 20)   // weight
+	binary.BigEndian.PutUint16(data[4:6], 5060) // port
+	data = append(data, encodeTestName("sip.example.com")...)
+
+	srvs, err := parseSRVRecords([]dnsRR{{typ: dnsTypeSRV, data: data}})
+	if err != nil {
+		t.Fatalf("parseSRVRecords: %v", err)
+	}
+	if len(srvs) != 1 {
+		t.Fatalf("got %d SRV records, want 1", len(srvs))
+	}
+	srv := srvs[0]
+	if srv.Priority != 10 || srv.Weight != 20 || srv.Port != 5060 || srv.Target != "sip.example.com" {
+		t.Errorf("srv = %+v, want {10 20 5060 sip.example.com}", *srv)
+	}
+}
+
+func TestParseTXTRecords(t *testing.T) {
+	data := append([]byte{byte(len("hello"))}, "hello"...)
+	data = append(data, byte(len("world")))
+	data = append(data, "world"...)
+
+	texts, err := parseTXTRecords([]dnsRR{{typ: dnsTypeTXT, data: data}})
+	if err != nil {
+		t.Fatalf("parseTXTRecords: %v", err)
+	}
+	want := []string{"hello", "world"}
+	if len(texts) != 2 || texts[0] != want[0] || texts[1] != want[1] {
+		t.Errorf("texts = %v, want %v", texts, want)
+	}
+}
+
+// buildTestResponse assembles a minimal DNS response message with the given
+// id, echoing query's question section (if any) and appending rrs as answer
+// records, mirroring the wire shape buildQuery/parseResponse operate on.
+func buildTestResponse(t *testing.T, id uint16, query []byte, rrs []dnsRR) []byte {
+	t.Helper()
+
+	var msg []byte
+	header := make([]byte, 12)
+	binary.BigEndian.PutUint16(header[0:], id)
+	header[2] = 0x81 // QR + RD
+	if query != nil {
+		binary.BigEndian.PutUint16(header[4:], 1) // QDCOUNT
+	}
+	binary.BigEndian.PutUint16(header[6:], uint16(len(rrs))) // ANCOUNT
+	msg = append(msg, header...)
+
+	if query != nil {
+		msg = append(msg, query[12:]...) // copy the question section verbatim
+	}
+
+	for _, rr := range rrs {
+		msg = append(msg, encodeTestName("example.com")...)
+		rrHeader := make([]byte, 10)
+		binary.BigEndian.PutUint16(rrHeader[0:], rr.typ)
+		binary.BigEndian.PutUint16(rrHeader[2:], dnsClassIN)
+		binary.BigEndian.PutUint16(rrHeader[8:], uint16(len(rr.data)))
+		msg = append(msg, rrHeader...)
+		msg = append(msg, rr.data...)
+	}
+	return msg
+}
+
+func encodeTestName(name string) []byte {
+	var b []byte
+	for _, label := range splitTestLabels(name) {
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+func splitTestLabels(name string) []string {
+	var labels []string
+	start := 0
+	for i := 0; i < len(name); i++ {
+		if name[i] == '.' {
+			labels = append(labels, name[start:i])
+			start = i + 1
+		}
+	}
+	return append(labels, name[start:])
+}